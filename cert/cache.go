@@ -0,0 +1,237 @@
+package cert
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheSize = 1000
+	defaultCacheTTL  = 365 * 24 * time.Hour // matches typical leaf cert validity
+
+	// evictBeforeExpiry is how long before a cached cert's own expiry the
+	// sweeper proactively evicts it, so a long-running proxy never hands a
+	// client a leaf that's about to stop being valid.
+	evictBeforeExpiry = 30 * 24 * time.Hour
+)
+
+// Cache is an LRU-bounded, TTL-expiring cache of leaf certificates keyed by
+// SNI, sitting in front of CA.GetCert so repeat connections to the same host
+// don't pay the cost of minting (and signing) a new certificate every time.
+type Cache struct {
+	ca  *CA
+	dir string // optional on-disk persistence directory; empty disables it
+	ttl time.Duration
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	size  int
+}
+
+type cacheEntry struct {
+	sni       string
+	cert      *tls.Certificate
+	expiresAt time.Time
+}
+
+// NewCache wraps ca with an LRU cache of up to size entries (0 uses the
+// default of 1000), each cached for ttl (0 uses the default of one year). If
+// dir is non-empty, minted certificates are also persisted there as PEM
+// files named by a hash of the SNI, so they survive a restart.
+func NewCache(ca *CA, size int, ttl time.Duration, dir string) *Cache {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+
+	c := &Cache{
+		ca:    ca,
+		dir:   dir,
+		ttl:   ttl,
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Errorf("cert cache: create %v: %v\n", dir, err)
+		}
+	}
+
+	go c.sweepLoop()
+
+	return c
+}
+
+// GetCertificate returns a leaf certificate for sni, using the cache when
+// possible and falling back to the on-disk store, then finally minting a new
+// one via the underlying CA.
+func (c *Cache) GetCertificate(sni string) (*tls.Certificate, error) {
+	if cert, ok := c.get(sni); ok {
+		return cert, nil
+	}
+
+	if c.dir != "" {
+		if cert, ok := c.loadFromDisk(sni); ok {
+			c.put(sni, cert)
+			return cert, nil
+		}
+	}
+
+	cert, err := c.ca.GetCert(sni)
+	if err != nil {
+		return nil, err
+	}
+
+	c.put(sni, cert)
+	if c.dir != "" {
+		c.saveToDisk(sni, cert)
+	}
+	return cert, nil
+}
+
+func (c *Cache) get(sni string) (*tls.Certificate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[sni]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.ll.Remove(el)
+		delete(c.items, sni)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	return entry.cert, true
+}
+
+func (c *Cache) put(sni string, cert *tls.Certificate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[sni]; ok {
+		c.ll.MoveToFront(el)
+		el.Value.(*cacheEntry).cert = cert
+		return
+	}
+
+	entry := &cacheEntry{sni: sni, cert: cert, expiresAt: time.Now().Add(c.ttl)}
+	c.items[sni] = c.ll.PushFront(entry)
+
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).sni)
+	}
+}
+
+// sweepLoop periodically evicts cached certs whose leaf is within one month
+// of its own X.509 expiry, independent of the cache TTL.
+func (c *Cache) sweepLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.sweep()
+	}
+}
+
+func (c *Cache) sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for sni, el := range c.items {
+		entry := el.Value.(*cacheEntry)
+		leaf, err := x509.ParseCertificate(entry.cert.Certificate[0])
+		if err != nil {
+			continue
+		}
+		if now.After(leaf.NotAfter.Add(-evictBeforeExpiry)) {
+			c.ll.Remove(el)
+			delete(c.items, sni)
+		}
+	}
+}
+
+func (c *Cache) diskPath(sni string) string {
+	sum := sha256.Sum256([]byte(sni))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".pem")
+}
+
+func (c *Cache) loadFromDisk(sni string) (*tls.Certificate, bool) {
+	data, err := os.ReadFile(c.diskPath(sni))
+	if err != nil {
+		return nil, false
+	}
+
+	var certDER [][]byte
+	var keyDER []byte
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			certDER = append(certDER, block.Bytes)
+		case "PRIVATE KEY":
+			keyDER = block.Bytes
+		}
+	}
+	if len(certDER) == 0 || keyDER == nil {
+		return nil, false
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(keyDER)
+	if err != nil {
+		return nil, false
+	}
+
+	cert := &tls.Certificate{Certificate: certDER, PrivateKey: key}
+	leaf, err := x509.ParseCertificate(certDER[0])
+	if err != nil || time.Now().After(leaf.NotAfter.Add(-evictBeforeExpiry)) {
+		return nil, false
+	}
+	cert.Leaf = leaf
+
+	return cert, true
+}
+
+func (c *Cache) saveToDisk(sni string, cert *tls.Certificate) {
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		log.Errorf("cert cache: marshal key for %v: %v\n", sni, err)
+		return
+	}
+
+	var out []byte
+	for _, der := range cert.Certificate {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	out = append(out, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})...)
+
+	if err := os.WriteFile(c.diskPath(sni), out, 0o600); err != nil {
+		log.Errorf("cert cache: write %v: %v\n", sni, err)
+	}
+}
@@ -0,0 +1,140 @@
+package flow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// decodedBody is the lazily-parsed, cached view of a Request's body. It is
+// kept out of the Request struct itself and indexed by pointer so that
+// parsing stays opt-in: addons that never call Form/MultipartForm/JSON pay
+// nothing for it.
+type decodedBody struct {
+	kind          bodyKind
+	form          url.Values
+	multipartForm *multipart.Form
+	json          any
+}
+
+type bodyKind int
+
+const (
+	bodyKindNone bodyKind = iota
+	bodyKindForm
+	bodyKindMultipart
+	bodyKindJSON
+)
+
+var (
+	bodyCacheMu sync.Mutex
+	bodyCache   = make(map[*Request]*decodedBody)
+)
+
+func (r *Request) decoded() *decodedBody {
+	bodyCacheMu.Lock()
+	defer bodyCacheMu.Unlock()
+
+	if d, ok := bodyCache[r]; ok {
+		return d
+	}
+
+	d := &decodedBody{}
+	bodyCache[r] = d
+	return d
+}
+
+// ReleaseBody discards any Form/MultipartForm/JSON result cached for r. The
+// proxy calls this once a flow is done being processed; skipping it leaks
+// the cache entry (and the body it holds) for the life of the process, since
+// the cache map itself keeps r reachable.
+func (r *Request) ReleaseBody() {
+	bodyCacheMu.Lock()
+	delete(bodyCache, r)
+	bodyCacheMu.Unlock()
+}
+
+// Form lazily parses the request body as application/x-www-form-urlencoded.
+// The returned url.Values is cached on the flow, so mutating it in place and
+// letting the request continue re-serializes it into the outgoing body via
+// SyncBody.
+func (r *Request) Form() url.Values {
+	d := r.decoded()
+	if d.kind == bodyKindForm {
+		return d.form
+	}
+
+	values, _ := url.ParseQuery(string(r.Body))
+	if values == nil {
+		values = url.Values{}
+	}
+	d.kind = bodyKindForm
+	d.form = values
+	return values
+}
+
+// MultipartForm lazily parses the request body as multipart/form-data.
+func (r *Request) MultipartForm() (*multipart.Form, error) {
+	d := r.decoded()
+	if d.kind == bodyKindMultipart {
+		return d.multipartForm, nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("request body is not multipart/form-data")
+	}
+
+	form, err := multipart.NewReader(bytes.NewReader(r.Body), params["boundary"]).ReadForm(32 << 20)
+	if err != nil {
+		return nil, fmt.Errorf("parse multipart form: %w", err)
+	}
+
+	d.kind = bodyKindMultipart
+	d.multipartForm = form
+	return form, nil
+}
+
+// JSON lazily unmarshals the request body as JSON. The returned value is
+// cached on the flow; if it underlying type is a map or slice, mutating it
+// in place and letting the request continue re-serializes it via SyncBody.
+func (r *Request) JSON() (any, error) {
+	d := r.decoded()
+	if d.kind == bodyKindJSON {
+		return d.json, nil
+	}
+
+	var v any
+	if err := json.Unmarshal(r.Body, &v); err != nil {
+		return nil, fmt.Errorf("unmarshal json body: %w", err)
+	}
+
+	d.kind = bodyKindJSON
+	d.json = v
+	return v, nil
+}
+
+// SyncBody re-serializes a previously decoded Form or JSON result back into
+// r.Body, picking up any in-place mutation an addon made to the value
+// returned by Form or JSON. It is a no-op if neither accessor was used; a
+// body decoded via MultipartForm is left untouched, as re-encoding
+// multipart bodies is not supported.
+func (r *Request) SyncBody() error {
+	d := r.decoded()
+	switch d.kind {
+	case bodyKindForm:
+		r.Body = []byte(d.form.Encode())
+	case bodyKindJSON:
+		body, err := json.Marshal(d.json)
+		if err != nil {
+			return fmt.Errorf("marshal json body: %w", err)
+		}
+		r.Body = body
+	}
+	return nil
+}
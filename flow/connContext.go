@@ -0,0 +1,111 @@
+package flow
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+)
+
+type ctxKeyType int
+
+// ConnContextKey is the context.Context key under which the *ConnContext for
+// a connection is stored; proxy.go's http.Server.ConnContext hook sets it
+// once per accepted connection, and every request read off that connection
+// shares the same value.
+const ConnContextKey ctxKeyType = 0
+
+// ClientConn describes the client side of a proxied connection.
+type ClientConn struct {
+	Conn net.Conn
+	Tls  bool
+}
+
+// ServerConn describes the proxy's connection to the real, upstream server.
+// It is populated lazily by InitHttpServer/InitHttpsServer, once the first
+// request on the connection actually needs to reach out.
+type ServerConn struct {
+	Conn   net.Conn
+	Client *http.Client
+}
+
+// ConnContext carries state shared by every request sent over one client
+// connection (HTTP keep-alive, or all the requests tunneled through a single
+// CONNECT/MITM session).
+type ConnContext struct {
+	Client *ClientConn
+	Server *ServerConn
+
+	// Username is the identity proxy.authenticate() established for this
+	// connection, recorded once on the initial CONNECT (or plain proxy
+	// request) and then shared by every request decrypted off of it, since
+	// those never carry their own Proxy-Authorization header.
+	Username string
+}
+
+// NewConnContext builds the ConnContext for a freshly accepted client
+// connection.
+func NewConnContext(c net.Conn) *ConnContext {
+	return &ConnContext{
+		Client: &ClientConn{Conn: c},
+	}
+}
+
+// InitHttpServer lazily dials the real server used to forward a plain HTTP
+// request, memoizing it on ctx.Server so later requests on the same
+// connection reuse it.
+//
+// configureTransport, if non-nil, is called with the freshly built Transport
+// before it is wrapped, so the caller can point it at an upstream proxy (set
+// Proxy/ProxyConnectHeader, or replace DialContext for a SOCKS5 upstream);
+// whatever DialContext it leaves in place (or the default dialer, if none)
+// is then wrapped so wrapConn still applies to the connection that actually
+// gets dialed — this keeps the ServerDisconnected addon hook and
+// ctx.Server.Conn accurate whether or not an upstream proxy is configured.
+//
+// wrapConn lets the caller observe/wrap the raw connection (e.g. to fire
+// ServerDisconnected on Close); onConnected fires once the client is ready,
+// to trigger the ServerConnected addon hook. It is called directly from
+// InitHttpServer, before any connection has been dialed.
+func (ctx *ConnContext) InitHttpServer(sslInsecure bool, configureTransport func(*http.Transport), wrapConn func(net.Conn) net.Conn, onConnected func()) {
+	if ctx.Server != nil {
+		return
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: sslInsecure}, // #nosec G402 -- opt-in via Options.SslInsecure
+	}
+
+	ctx.Server = &ServerConn{}
+
+	if configureTransport != nil {
+		configureTransport(transport)
+	}
+
+	baseDial := transport.DialContext
+	if baseDial == nil {
+		baseDial = (&net.Dialer{}).DialContext
+	}
+
+	transport.DialContext = func(dialCtx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := baseDial(dialCtx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		conn = wrapConn(conn)
+		ctx.Server.Conn = conn
+		return conn, nil
+	}
+
+	ctx.Server.Client = &http.Client{Transport: transport}
+
+	onConnected()
+}
+
+// InitHttpsServer is the HTTPS analogue of InitHttpServer, used for requests
+// that Middle has already decrypted; the transport still dials the real
+// server in the clear and lets http.Transport negotiate TLS itself when the
+// request's URL scheme is "https".
+func (ctx *ConnContext) InitHttpsServer(sslInsecure bool, configureTransport func(*http.Transport), wrapConn func(net.Conn) net.Conn, onConnected func()) {
+	ctx.InitHttpServer(sslInsecure, configureTransport, wrapConn, onConnected)
+}
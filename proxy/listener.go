@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listen opens a net.Listener for addr. Besides a plain TCP address
+// ("host:port", or "" for the default), it accepts:
+//
+//	unix:///var/run/mitm.sock   a Unix domain socket
+//	fd://3                      a file descriptor inherited from the parent
+//	                            (e.g. systemd socket activation)
+func listen(addr string) (net.Listener, error) {
+	scheme, rest, ok := strings.Cut(addr, "://")
+	if !ok {
+		if addr == "" {
+			addr = ":http"
+		}
+		return net.Listen("tcp", addr)
+	}
+
+	switch scheme {
+	case "unix":
+		return net.Listen("unix", rest)
+
+	case "fd":
+		fdNum, err := strconv.Atoi(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fd listener addr %q: %w", addr, err)
+		}
+		f := os.NewFile(uintptr(fdNum), fmt.Sprintf("listener-fd-%d", fdNum))
+		ln, err := net.FileListener(f)
+		if err != nil {
+			return nil, fmt.Errorf("wrap inherited fd %d as listener: %w", fdNum, err)
+		}
+		f.Close() // net.FileListener dups fdNum; the original is no longer needed
+		return ln, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported listener scheme %q", scheme)
+	}
+}
@@ -46,10 +46,16 @@ type pipeConn struct {
 	connContext *flow.ConnContext
 }
 
+// pipeReaderBufferSize must cover a full TLS record (header + up to 16KB of
+// payload) so maybePassthrough can Peek an entire ClientHello; the default
+// bufio size (4096 bytes) is routinely too small for modern browsers' hellos
+// (ALPN, key-share, padding, session tickets, ...).
+const pipeReaderBufferSize = 16*1024 + 5
+
 func newPipeConn(c net.Conn, req *http.Request) *pipeConn {
 	return &pipeConn{
 		Conn:        c,
-		r:           bufio.NewReader(c),
+		r:           bufio.NewReaderSize(c, pipeReaderBufferSize),
 		host:        req.Host,
 		remoteAddr:  req.RemoteAddr,
 		connContext: req.Context().Value(flow.ConnContextKey).(*flow.ConnContext),
@@ -70,10 +76,11 @@ func (c *pipeConn) RemoteAddr() net.Addr {
 
 // Middle: man-in-the-middle
 type Middle struct {
-	Proxy    *Proxy
-	CA       *cert.CA
-	Listener net.Listener
-	Server   *http.Server
+	Proxy     *Proxy
+	CA        *cert.CA
+	CertCache *cert.Cache
+	Listener  net.Listener
+	Server    *http.Server
 }
 
 func NewMiddle(proxy *Proxy, caPath string) (Interceptor, error) {
@@ -82,9 +89,12 @@ func NewMiddle(proxy *Proxy, caPath string) (Interceptor, error) {
 		return nil, err
 	}
 
+	certCache := cert.NewCache(ca, proxy.Opts.CertCacheSize, proxy.Opts.CertCacheTTL, proxy.Opts.CertCacheDir)
+
 	m := &Middle{
-		Proxy: proxy,
-		CA:    ca,
+		Proxy:     proxy,
+		CA:        ca,
+		CertCache: certCache,
 	}
 
 	server := &http.Server{
@@ -99,7 +109,7 @@ func NewMiddle(proxy *Proxy, caPath string) (Interceptor, error) {
 		TLSConfig: &tls.Config{
 			GetCertificate: func(chi *tls.ClientHelloInfo) (*tls.Certificate, error) {
 				log.Debugf("Middle GetCertificate ServerName: %v\n", chi.ServerName)
-				return ca.GetCert(chi.ServerName)
+				return certCache.GetCertificate(chi.ServerName)
 			},
 		},
 	}
@@ -154,8 +164,18 @@ func (m *Middle) intercept(pipeServerConn *pipeConn) {
 	if buf[0] == 0x16 && buf[1] == 0x03 && buf[2] <= 0x03 {
 		// tls
 		pipeServerConn.connContext.Client.Tls = true
+
+		if m.Proxy.hasPassthroughPolicy() && m.maybePassthrough(pipeServerConn) {
+			return
+		}
+
 		pipeServerConn.connContext.InitHttpsServer(
 			m.Proxy.Opts.SslInsecure,
+			func(t *http.Transport) {
+				if m.Proxy.upstream != nil {
+					m.Proxy.upstream.configure(t)
+				}
+			},
 			func(c net.Conn) net.Conn {
 				return &serverConn{
 					Conn:    c,
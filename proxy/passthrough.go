@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MITMDecider lets callers extend the PassthroughHosts policy
+// programmatically, e.g. to bypass MITM based on something other than the
+// hostname. A true return means "do not intercept, splice the raw bytes
+// instead." It is consulted only for hosts that no PassthroughHosts entry
+// matched, so it cannot force interception of a host those entries match.
+type MITMDecider func(host string) bool
+
+// shouldPassthrough reports whether host should be tunneled transparently
+// rather than have its TLS terminated, matching PassthroughHosts and, if
+// set, Opts.MITMDecider. Each PassthroughHosts entry is a glob pattern (e.g.
+// "*.example.com"), unless it's prefixed with "regex:", in which case the
+// remainder is compiled and matched as a regular expression instead (e.g.
+// "regex:^(a|b)\\.example\\.com$").
+func (proxy *Proxy) shouldPassthrough(host string) bool {
+	for _, pattern := range proxy.Opts.PassthroughHosts {
+		if re, ok := strings.CutPrefix(pattern, "regex:"); ok {
+			matched, err := regexp.MatchString(re, host)
+			if err != nil {
+				log.Errorf("invalid PassthroughHosts regex %q: %v\n", re, err)
+				continue
+			}
+			if matched {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	if proxy.Opts.MITMDecider != nil {
+		return proxy.Opts.MITMDecider(host)
+	}
+	return false
+}
+
+func (proxy *Proxy) hasPassthroughPolicy() bool {
+	return len(proxy.Opts.PassthroughHosts) > 0 || proxy.Opts.MITMDecider != nil
+}
+
+// peekClientHello peeks the full TLS record containing the ClientHello,
+// without consuming it, so intercept's normal MITM path still sees it.
+func peekClientHello(c *pipeConn) ([]byte, error) {
+	header, err := c.Peek(5)
+	if err != nil {
+		return nil, err
+	}
+	recordLen := int(header[3])<<8 | int(header[4])
+	return c.Peek(5 + recordLen)
+}
+
+// maybePassthrough inspects the SNI of an incoming TLS connection and, if it
+// matches the passthrough policy, splices it directly to the real server
+// instead of terminating TLS. It reports whether the connection was handled.
+func (m *Middle) maybePassthrough(pipeServerConn *pipeConn) bool {
+	record, err := peekClientHello(pipeServerConn)
+	if err != nil {
+		log.Debugf("passthrough: peek ClientHello: %v\n", err)
+		return false
+	}
+
+	sni, err := clientHelloServerName(record)
+	if err != nil {
+		log.Debugf("passthrough: parse SNI: %v\n", err)
+		return false
+	}
+
+	if !m.Proxy.shouldPassthrough(sni) {
+		return false
+	}
+
+	// pipeServerConn.host is the actual CONNECT target (host:port); the SNI
+	// is only used to evaluate the passthrough policy above, since a client
+	// can CONNECT to a non-443 port while still sending a matching SNI.
+	outbound, err := dialPassthrough(m.Proxy, pipeServerConn.host)
+	if err != nil {
+		log.Errorf("passthrough: dial %v: %v\n", pipeServerConn.host, err)
+		pipeServerConn.Close()
+		return true
+	}
+	defer outbound.Close()
+
+	transfer(log, outbound, pipeServerConn)
+	return true
+}
+
+func dialPassthrough(proxy *Proxy, addr string) (net.Conn, error) {
+	if proxy.upstream != nil {
+		if proxy.upstream.url.Scheme != "socks5" {
+			return proxy.upstream.dialCONNECT(addr)
+		}
+		t := &http.Transport{}
+		proxy.upstream.configure(t)
+		if t.DialContext != nil {
+			return t.DialContext(context.Background(), "tcp", addr)
+		}
+	}
+	return net.DialTimeout("tcp", addr, 10*time.Second)
+}
@@ -0,0 +1,113 @@
+package proxy
+
+import "fmt"
+
+// clientHelloServerName does a minimal parse of a TLS ClientHello record to
+// extract the SNI (server_name) extension, without pulling in a full TLS
+// handshake parser. buf must contain at least the ClientHello handshake
+// message (the caller typically gets this via a buffered Peek).
+//
+// https://github.com/mitmproxy/mitmproxy/blob/main/mitmproxy/net/tls.py is a
+// reference implementation of the same parsing in Python.
+func clientHelloServerName(buf []byte) (string, error) {
+	// TLS record header: type(1) version(2) length(2)
+	if len(buf) < 5 || buf[0] != 0x16 {
+		return "", fmt.Errorf("not a TLS handshake record")
+	}
+	recordLen := int(buf[3])<<8 | int(buf[4])
+	buf = buf[5:]
+	if len(buf) < recordLen {
+		return "", fmt.Errorf("truncated TLS record")
+	}
+
+	// Handshake header: type(1) length(3)
+	if len(buf) < 4 || buf[0] != 0x01 {
+		return "", fmt.Errorf("not a ClientHello handshake message")
+	}
+	buf = buf[4:]
+
+	// ClientHello body: version(2) random(32) session_id
+	if len(buf) < 34 {
+		return "", fmt.Errorf("truncated ClientHello")
+	}
+	buf = buf[34:]
+
+	if len(buf) < 1 {
+		return "", fmt.Errorf("truncated session id")
+	}
+	sessionIDLen := int(buf[0])
+	buf = buf[1:]
+	if len(buf) < sessionIDLen {
+		return "", fmt.Errorf("truncated session id")
+	}
+	buf = buf[sessionIDLen:]
+
+	// cipher_suites
+	if len(buf) < 2 {
+		return "", fmt.Errorf("truncated cipher suites")
+	}
+	cipherSuitesLen := int(buf[0])<<8 | int(buf[1])
+	buf = buf[2:]
+	if len(buf) < cipherSuitesLen {
+		return "", fmt.Errorf("truncated cipher suites")
+	}
+	buf = buf[cipherSuitesLen:]
+
+	// compression_methods
+	if len(buf) < 1 {
+		return "", fmt.Errorf("truncated compression methods")
+	}
+	compressionLen := int(buf[0])
+	buf = buf[1:]
+	if len(buf) < compressionLen {
+		return "", fmt.Errorf("truncated compression methods")
+	}
+	buf = buf[compressionLen:]
+
+	if len(buf) < 2 {
+		// no extensions, e.g. a very old ClientHello
+		return "", fmt.Errorf("no SNI extension")
+	}
+	extensionsLen := int(buf[0])<<8 | int(buf[1])
+	buf = buf[2:]
+	if len(buf) < extensionsLen {
+		return "", fmt.Errorf("truncated extensions")
+	}
+	buf = buf[:extensionsLen]
+
+	const extensionServerName = 0x0000
+	for len(buf) >= 4 {
+		extType := int(buf[0])<<8 | int(buf[1])
+		extLen := int(buf[2])<<8 | int(buf[3])
+		buf = buf[4:]
+		if len(buf) < extLen {
+			return "", fmt.Errorf("truncated extension body")
+		}
+		extBody := buf[:extLen]
+		buf = buf[extLen:]
+
+		if extType != extensionServerName {
+			continue
+		}
+
+		// server_name_list: length(2) then entries of type(1) length(2) name
+		if len(extBody) < 2 {
+			return "", fmt.Errorf("truncated server name list")
+		}
+		entries := extBody[2:]
+		for len(entries) >= 3 {
+			nameType := entries[0]
+			nameLen := int(entries[1])<<8 | int(entries[2])
+			entries = entries[3:]
+			if len(entries) < nameLen {
+				return "", fmt.Errorf("truncated server name entry")
+			}
+			if nameType == 0x00 { // host_name
+				return string(entries[:nameLen]), nil
+			}
+			entries = entries[nameLen:]
+		}
+	}
+
+	return "", fmt.Errorf("no SNI extension")
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query is a parsed filter expression, e.g.
+//
+//	method=POST host~example.com status>=400 body~"token"
+//
+// Clauses are whitespace-separated and implicitly ANDed together. Supported
+// fields are method, host, url, status and body; supported operators are
+// = (exact match), ~ (substring/LIKE) and the numeric comparisons
+// >, >=, <, <= (status only).
+type Query struct {
+	clauses []clause
+}
+
+type clauseOp string
+
+const (
+	opEq   clauseOp = "="
+	opLike clauseOp = "~"
+	opGT   clauseOp = ">"
+	opGE   clauseOp = ">="
+	opLT   clauseOp = "<"
+	opLE   clauseOp = "<="
+)
+
+var fieldColumns = map[string]string{
+	"method": "method",
+	"host":   "host",
+	"url":    "url",
+	"status": "status_code",
+	"body":   "res_body_text",
+}
+
+type clause struct {
+	field string
+	op    clauseOp
+	value string
+}
+
+// ParseFilter parses a filter expression into a Query.
+func ParseFilter(s string) (*Query, error) {
+	q := &Query{}
+	for _, tok := range splitClauses(s) {
+		if tok == "" {
+			continue
+		}
+		c, err := parseClause(tok)
+		if err != nil {
+			return nil, fmt.Errorf("parse filter %q: %w", tok, err)
+		}
+		q.clauses = append(q.clauses, c)
+	}
+	return q, nil
+}
+
+// splitClauses splits on whitespace, respecting double-quoted values so that
+// `body~"two words"` stays one token.
+func splitClauses(s string) []string {
+	var toks []string
+	var b strings.Builder
+	inQuotes := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			b.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuotes:
+			if b.Len() > 0 {
+				toks = append(toks, b.String())
+				b.Reset()
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() > 0 {
+		toks = append(toks, b.String())
+	}
+	return toks
+}
+
+func parseClause(tok string) (clause, error) {
+	for _, op := range []clauseOp{opGE, opLE, opEq, opLike, opGT, opLT} {
+		idx := strings.Index(tok, string(op))
+		if idx <= 0 {
+			continue
+		}
+		field := tok[:idx]
+		if _, ok := fieldColumns[field]; !ok {
+			continue
+		}
+		if op != opEq && op != opLike && field != "status" {
+			continue
+		}
+		value := strings.Trim(tok[idx+len(op):], `"`)
+		return clause{field: field, op: op, value: value}, nil
+	}
+	return clause{}, fmt.Errorf("no recognized field/operator")
+}
+
+// toSQL renders the query as a SQL WHERE clause (without the WHERE keyword)
+// plus its bound arguments, suitable for an indexed predicate against the
+// flows table.
+func (q *Query) toSQL() (string, []any) {
+	if len(q.clauses) == 0 {
+		return "1=1", nil
+	}
+
+	var preds []string
+	var args []any
+	for _, c := range q.clauses {
+		col := fieldColumns[c.field]
+		switch c.op {
+		case opEq:
+			preds = append(preds, col+" = ?")
+			args = append(args, c.value)
+		case opLike:
+			preds = append(preds, col+" LIKE ?")
+			args = append(args, "%"+c.value+"%")
+		case opGT, opGE, opLT, opLE:
+			n, err := strconv.Atoi(c.value)
+			if err != nil {
+				continue
+			}
+			preds = append(preds, fmt.Sprintf("%s %s ?", col, string(c.op)))
+			args = append(args, n)
+		}
+	}
+	return strings.Join(preds, " AND "), args
+}
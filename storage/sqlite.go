@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/lqqyt2423/go-mitmproxy/flow"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS flows (
+	id TEXT PRIMARY KEY,
+	method TEXT,
+	url TEXT,
+	host TEXT,
+	status_code INTEGER,
+	req_header TEXT,
+	req_body BLOB,
+	req_body_blob TEXT,
+	res_header TEXT,
+	res_body BLOB,
+	res_body_blob TEXT,
+	res_body_text TEXT,
+	client_addr TEXT,
+	tls INTEGER,
+	username TEXT,
+	started_at DATETIME,
+	duration_ms INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_flows_method ON flows(method);
+CREATE INDEX IF NOT EXISTS idx_flows_host ON flows(host);
+CREATE INDEX IF NOT EXISTS idx_flows_status ON flows(status_code);
+`
+
+// textExcerptLimit bounds how much of a response body is copied into
+// res_body_text for filtering, regardless of whether the full body was
+// spilled to a blob or kept inline.
+const textExcerptLimit = 8192
+
+// SQLiteStore is the default Store implementation, backed by a single
+// SQLite database file plus a directory of content-addressed body blobs.
+type SQLiteStore struct {
+	db      *sql.DB
+	blobDir string
+
+	// bodies larger than this are spilled to blobDir instead of being
+	// inlined in the flows table
+	inlineBodyLimit int64
+}
+
+// NewSQLiteStore opens (creating if needed) a SQLite database at path, with
+// a sibling "blobs" directory for large bodies.
+func NewSQLiteStore(path string, inlineBodyLimit int64) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite store %v: %w", path, err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init sqlite schema: %w", err)
+	}
+
+	blobDir := filepath.Join(filepath.Dir(path), "blobs")
+	if err := os.MkdirAll(blobDir, 0o755); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create blob dir %v: %w", blobDir, err)
+	}
+
+	if inlineBodyLimit <= 0 {
+		inlineBodyLimit = 1024 * 1024 // default: 1mb
+	}
+
+	return &SQLiteStore{db: db, blobDir: blobDir, inlineBodyLimit: inlineBodyLimit}, nil
+}
+
+// Meta carries the per-flow information that isn't part of flow.Flow itself:
+// how long the round trip took and which client connection it came from.
+type Meta struct {
+	Duration   time.Duration
+	ClientAddr string
+	TLS        bool
+	Username   string
+}
+
+// Save persists a captured flow. Bodies larger than inlineBodyLimit are
+// spilled to content-addressed blob files so identical bodies are stored once.
+func (s *SQLiteStore) Save(f *flow.Flow, meta Meta) error {
+	reqHeader, err := json.Marshal(f.Request.Header)
+	if err != nil {
+		return fmt.Errorf("marshal request header: %w", err)
+	}
+
+	var resHeader []byte
+	var statusCode int
+	var resBody []byte
+	if f.Response != nil {
+		resHeader, err = json.Marshal(f.Response.Header)
+		if err != nil {
+			return fmt.Errorf("marshal response header: %w", err)
+		}
+		statusCode = f.Response.StatusCode
+		resBody = f.Response.Body
+	}
+
+	reqBody, reqBlob, err := s.storeBody(f.Request.Body)
+	if err != nil {
+		return err
+	}
+	resBodyInline, resBlob, err := s.storeBody(resBody)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		`INSERT OR REPLACE INTO flows
+			(id, method, url, host, status_code, req_header, req_body, req_body_blob, res_header, res_body, res_body_blob, res_body_text, client_addr, tls, username, started_at, duration_ms)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		f.Id.String(), f.Request.Method, f.Request.URL.String(), f.Request.URL.Host, statusCode,
+		string(reqHeader), reqBody, reqBlob,
+		string(resHeader), resBodyInline, resBlob, textExcerpt(resBody),
+		meta.ClientAddr, meta.TLS, meta.Username,
+		time.Now(), meta.Duration.Milliseconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("insert flow %v: %w", f.Id, err)
+	}
+	return nil
+}
+
+// textExcerpt bounds how much of body is kept searchable in res_body_text,
+// independent of whether the full body was spilled to a blob.
+func textExcerpt(body []byte) string {
+	if len(body) > textExcerptLimit {
+		body = body[:textExcerptLimit]
+	}
+	return string(body)
+}
+
+// storeBody returns the body to inline in the flows table, plus the blob
+// filename if it was spilled instead.
+func (s *SQLiteStore) storeBody(body []byte) (inline []byte, blobName string, err error) {
+	if int64(len(body)) <= s.inlineBodyLimit {
+		return body, "", nil
+	}
+
+	sum := sha256.Sum256(body)
+	name := hex.EncodeToString(sum[:])
+	path := filepath.Join(s.blobDir, name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := os.WriteFile(path, body, 0o644); err != nil {
+			return nil, "", fmt.Errorf("write blob %v: %w", name, err)
+		}
+	}
+	return nil, name, nil
+}
+
+func (s *SQLiteStore) readBody(inline []byte, blobName string) []byte {
+	if blobName == "" {
+		return inline
+	}
+	body, err := os.ReadFile(filepath.Join(s.blobDir, blobName))
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+const selectColumns = `id, method, url, host, status_code, req_header, req_body, req_body_blob, res_header, res_body, res_body_blob, client_addr, tls, username, started_at, duration_ms`
+
+// Get looks up a single flow by id.
+func (s *SQLiteStore) Get(id string) (*Record, error) {
+	row := s.db.QueryRow(`SELECT `+selectColumns+` FROM flows WHERE id = ?`, id)
+	return s.scan(row)
+}
+
+// Query runs a filter expression (see ParseFilter) and returns a streaming
+// iterator over matching records.
+func (s *SQLiteStore) Query(filter string) (Iterator, error) {
+	q, err := ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	where, args := q.toSQL()
+	rows, err := s.db.Query(`SELECT `+selectColumns+` FROM flows WHERE `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query flows: %w", err)
+	}
+	return &sqliteIterator{store: s, rows: rows}, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (s *SQLiteStore) scan(row scanner) (*Record, error) {
+	var r Record
+	var reqBody, resBody []byte
+	var reqBlob, resBlob string
+	var durationMs int64
+	if err := row.Scan(&r.ID, &r.Method, &r.URL, &r.Host, &r.StatusCode, &r.ReqHeader, &reqBody, &reqBlob, &r.ResHeader, &resBody, &resBlob, &r.ClientAddr, &r.TLS, &r.Username, &r.StartedAt, &durationMs); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("scan flow row: %w", err)
+	}
+	r.ReqBody = s.readBody(reqBody, reqBlob)
+	r.ResBody = s.readBody(resBody, resBlob)
+	r.Duration = time.Duration(durationMs) * time.Millisecond
+	return &r, nil
+}
+
+type sqliteIterator struct {
+	store *SQLiteStore
+	rows  *sql.Rows
+}
+
+func (it *sqliteIterator) Next() (*Record, error) {
+	if !it.rows.Next() {
+		return nil, it.rows.Err()
+	}
+	return it.store.scan(it.rows)
+}
+
+func (it *sqliteIterator) Close() error {
+	return it.rows.Close()
+}
@@ -0,0 +1,43 @@
+// Package storage persists flows captured by the proxy so they can be
+// inspected and queried after the fact.
+package storage
+
+import (
+	"time"
+
+	"github.com/lqqyt2423/go-mitmproxy/flow"
+)
+
+// Record is the durable representation of a captured flow.Flow.
+type Record struct {
+	ID         string
+	Method     string
+	URL        string
+	Host       string
+	StatusCode int
+	ReqHeader  string // JSON-encoded http.Header
+	ReqBody    []byte
+	ResHeader  string
+	ResBody    []byte
+	ClientAddr string
+	TLS        bool
+	Username   string
+	StartedAt  time.Time
+	Duration   time.Duration
+}
+
+// Store persists flows and makes them queryable later, potentially from a
+// different process than the one that captured them.
+type Store interface {
+	Save(f *flow.Flow, meta Meta) error
+	Get(id string) (*Record, error)
+	Query(filter string) (Iterator, error)
+	Close() error
+}
+
+// Iterator streams Query results one Record at a time. Next returns
+// (nil, nil) once the result set is exhausted.
+type Iterator interface {
+	Next() (*Record, error)
+	Close() error
+}